@@ -397,6 +397,9 @@ func createContainer(cfg *containerConfig) (_ *container, err error) {
 			lopts.ConsolePipe = cfg.VMConsolePipe
 		case *uvm.OptionsWCOW:
 			wopts := opts.(*uvm.OptionsWCOW)
+			if cfg.VMConsolePipe != "" {
+				wopts.ComPorts = map[string]string{"0": cfg.VMConsolePipe}
+			}
 
 			// In order for the UVM sandbox.vhdx not to collide with the actual
 			// nested Argon sandbox.vhdx we append the \vm folder to the last entry
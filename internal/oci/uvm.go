@@ -113,6 +113,13 @@ const (
 	annotationBootFilesRootPath          = "io.microsoft.virtualmachine.lcow.bootfilesrootpath"
 	annotationStorageQoSBandwidthMaximum = "io.microsoft.virtualmachine.storageqos.bandwidthmaximum"
 	annotationStorageQoSIopsMaximum      = "io.microsoft.virtualmachine.storageqos.iopsmaximum"
+	// annotationComPortPrefix connects a COM port of a WCOW utility VM to a
+	// named pipe. The port ("0" for COM1, "1" for COM2) follows the prefix, eg
+	// "io.microsoft.virtualmachine.devices.comports.0" = "\\.\pipe\debugpipe".
+	annotationComPortPrefix = "io.microsoft.virtualmachine.devices.comports."
+	// annotationConsoleLogFile appends the output of COM1 of a WCOW utility VM
+	// to the file at this path on the host.
+	annotationConsoleLogFile = "io.microsoft.virtualmachine.wcow.consolelogfile"
 )
 
 // parseAnnotationsBool searches `a` for `key` and if found verifies that the
@@ -300,6 +307,25 @@ func parseAnnotationsString(a map[string]string, key string, def string) string
 	return def
 }
 
+// parseAnnotationsComPorts searches `a` for keys starting with `prefix` and
+// returns a map of the remainder of each key to its value. If no key is found
+// returns `def`.
+func parseAnnotationsComPorts(a map[string]string, prefix string, def map[string]string) map[string]string {
+	var ports map[string]string
+	for k, v := range a {
+		if strings.HasPrefix(k, prefix) {
+			if ports == nil {
+				ports = make(map[string]string)
+			}
+			ports[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	if ports == nil {
+		return def
+	}
+	return ports
+}
+
 // SpecToUVMCreateOpts parses `s` and returns either `*uvm.OptionsLCOW` or
 // `*uvm.OptionsWCOW`.
 func SpecToUVMCreateOpts(s *specs.Spec, id, owner string) (interface{}, error) {
@@ -337,6 +363,8 @@ func SpecToUVMCreateOpts(s *specs.Spec, id, owner string) (interface{}, error) {
 		wopts.ProcessorWeight = ParseAnnotationsCPUWeight(s, annotationProcessorWeight, wopts.ProcessorWeight)
 		wopts.StorageQoSBandwidthMaximum = ParseAnnotationsStorageBps(s, annotationStorageQoSBandwidthMaximum, wopts.StorageQoSBandwidthMaximum)
 		wopts.StorageQoSIopsMaximum = ParseAnnotationsStorageIops(s, annotationStorageQoSIopsMaximum, wopts.StorageQoSIopsMaximum)
+		wopts.ComPorts = parseAnnotationsComPorts(s.Annotations, annotationComPortPrefix, wopts.ComPorts)
+		wopts.ConsoleLogFile = parseAnnotationsString(s.Annotations, annotationConsoleLogFile, wopts.ConsoleLogFile)
 		return wopts, nil
 	}
 	return nil, errors.New("cannot create UVM opts spec is not LCOW or WCOW")
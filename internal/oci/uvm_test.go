@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func Test_SpecToUVMCreateOpts_WCOW_ComPorts(t *testing.T) {
+	s := &specs.Spec{
+		Windows: &specs.Windows{
+			HyperV: &specs.WindowsHyperV{},
+		},
+		Annotations: map[string]string{
+			annotationComPortPrefix + "1": `\\.\pipe\debugpipe`,
+			annotationConsoleLogFile:      `c:\console.log`,
+		},
+	}
+	opts, err := SpecToUVMCreateOpts(s, t.Name(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wopts := opts.(*uvm.OptionsWCOW)
+	if len(wopts.ComPorts) != 1 || wopts.ComPorts["1"] != `\\.\pipe\debugpipe` {
+		t.Fatalf("unexpected ComPorts: %v", wopts.ComPorts)
+	}
+	if wopts.ConsoleLogFile != `c:\console.log` {
+		t.Fatalf("unexpected ConsoleLogFile: %s", wopts.ConsoleLogFile)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_WCOW_NoComPorts(t *testing.T) {
+	s := &specs.Spec{
+		Windows: &specs.Windows{
+			HyperV: &specs.WindowsHyperV{},
+		},
+	}
+	opts, err := SpecToUVMCreateOpts(s, t.Name(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wopts := opts.(*uvm.OptionsWCOW); wopts.ComPorts != nil || wopts.ConsoleLogFile != "" {
+		t.Fatalf("unexpected console options: %v %s", wopts.ComPorts, wopts.ConsoleLogFile)
+	}
+}
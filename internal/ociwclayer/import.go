@@ -2,7 +2,9 @@ package ociwclayer
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -55,11 +57,22 @@ func ImportLayer(r io.Reader, path string, parentLayerPaths []string) (int64, er
 	return n, nil
 }
 
+// pendingStream is an alternate data stream that was found in the tar stream
+// before its parent file or directory.
+type pendingStream struct {
+	name string // Stream name including the leading colon, eg `:stream`
+	data []byte
+}
+
 func writeLayerFromTar(r io.Reader, w hcsshim.LayerWriter, root string) (int64, error) {
 	t := tar.NewReader(r)
 	hdr, err := t.Next()
 	totalSize := int64(0)
 	buf := bufio.NewWriter(nil)
+	// Alternate data streams normally directly follow their parent and are
+	// consumed together with it. Some image builders interleave them, so
+	// streams seen before their parent are held until the parent is written.
+	pending := make(map[string][]pendingStream)
 	for err == nil {
 		base := path.Base(hdr.Name)
 		if strings.HasPrefix(base, whiteoutPrefix) {
@@ -75,6 +88,15 @@ func writeLayerFromTar(r io.Reader, w hcsshim.LayerWriter, root string) (int64,
 				return 0, err
 			}
 			hdr, err = t.Next()
+		} else if i := strings.Index(hdr.Name, ":"); i >= 0 {
+			parent := hdr.Name[:i]
+			var data []byte
+			data, err = ioutil.ReadAll(t)
+			if err != nil {
+				return 0, err
+			}
+			pending[parent] = append(pending[parent], pendingStream{name: hdr.Name[i:], data: data})
+			hdr, err = t.Next()
 		} else {
 			var (
 				name     string
@@ -89,20 +111,27 @@ func writeLayerFromTar(r io.Reader, w hcsshim.LayerWriter, root string) (int64,
 			if err != nil {
 				return 0, err
 			}
-			hdr, err = writeBackupStreamFromTarAndSaveMutatedFiles(buf, w, t, hdr, root)
+			key := strings.TrimSuffix(hdr.Name, "/")
+			streams := pending[key]
+			delete(pending, key)
+			hdr, err = writeBackupStreamFromTarAndSaveMutatedFiles(buf, w, t, hdr, root, streams)
 			totalSize += size
 		}
 	}
 	if err != io.EOF {
 		return 0, err
 	}
+	for parent, streams := range pending {
+		return 0, fmt.Errorf("%s%s: alternate data stream has no parent in the layer, or follows a parent that was already written", parent, streams[0].name)
+	}
 	return totalSize, nil
 }
 
 // writeBackupStreamFromTarAndSaveMutatedFiles reads data from a tar stream and
 // writes it to a backup stream, and also saves any files that will be mutated
-// by the import layer process to a backup location.
-func writeBackupStreamFromTarAndSaveMutatedFiles(buf *bufio.Writer, w io.Writer, t *tar.Reader, hdr *tar.Header, root string) (nextHdr *tar.Header, err error) {
+// by the import layer process to a backup location. Any `streams` which were
+// found earlier in the tar stream are appended as alternate data streams.
+func writeBackupStreamFromTarAndSaveMutatedFiles(buf *bufio.Writer, w io.Writer, t *tar.Reader, hdr *tar.Header, root string, streams []pendingStream) (nextHdr *tar.Header, err error) {
 	var bcdBackup *os.File
 	var bcdBackupWriter *winio.BackupFileWriter
 	if backupPath, ok := mutatedFiles[hdr.Name]; ok {
@@ -137,5 +166,31 @@ func writeBackupStreamFromTarAndSaveMutatedFiles(buf *bufio.Writer, w io.Writer,
 		}
 	}()
 
-	return backuptar.WriteBackupStreamFromTarFile(buf, t, hdr)
+	// Alternate data streams of a directory are named `dir:stream` whereas
+	// the directory entry itself is `dir/`. Match them against the name
+	// without the trailing slash so they are attached to the directory.
+	if hdr.Typeflag == tar.TypeDir && strings.HasSuffix(hdr.Name, "/") {
+		dirHdr := *hdr
+		dirHdr.Name = strings.TrimSuffix(hdr.Name, "/")
+		hdr = &dirHdr
+	}
+	nextHdr, err = backuptar.WriteBackupStreamFromTarFile(buf, t, hdr)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	bw := winio.NewBackupStreamWriter(buf)
+	for _, s := range streams {
+		bhdr := winio.BackupHeader{
+			Id:   winio.BackupAlternateData,
+			Size: int64(len(s.data)),
+			Name: s.name + ":$DATA",
+		}
+		if werr := bw.WriteHeader(&bhdr); werr != nil {
+			return nil, werr
+		}
+		if _, werr := bw.Write(s.data); werr != nil {
+			return nil, werr
+		}
+	}
+	return nextHdr, err
 }
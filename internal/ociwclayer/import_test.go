@@ -0,0 +1,155 @@
+package ociwclayer
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/archive/tar"
+)
+
+// testLayerWriter records the backup stream written for each file added to
+// the layer.
+type testLayerWriter struct {
+	files   map[string]*bytes.Buffer
+	current *bytes.Buffer
+}
+
+func newTestLayerWriter() *testLayerWriter {
+	return &testLayerWriter{files: make(map[string]*bytes.Buffer)}
+}
+
+func (w *testLayerWriter) Add(name string, fileInfo *winio.FileBasicInfo) error {
+	// Clean the name as the legacy layer writer does, so directories added
+	// as `dir\` are recorded as `dir`.
+	w.current = &bytes.Buffer{}
+	w.files[filepath.Clean(name)] = w.current
+	return nil
+}
+
+func (w *testLayerWriter) AddLink(name string, target string) error { return nil }
+func (w *testLayerWriter) Remove(name string) error                 { return nil }
+func (w *testLayerWriter) Close() error                             { return nil }
+
+func (w *testLayerWriter) Write(b []byte) (int, error) {
+	return w.current.Write(b)
+}
+
+// streams returns the alternate data streams and their contents found in the
+// backup stream written for `name`.
+func (w *testLayerWriter) streams(t *testing.T, name string) map[string]string {
+	b, ok := w.files[name]
+	if !ok {
+		t.Fatalf("%s was not added to the layer", name)
+	}
+	streams := make(map[string]string)
+	br := winio.NewBackupStreamReader(bytes.NewReader(b.Bytes()))
+	for {
+		bhdr, err := br.Next()
+		if err == io.EOF {
+			return streams
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bhdr.Id != winio.BackupAlternateData {
+			continue
+		}
+		data := &bytes.Buffer{}
+		if _, err := io.Copy(data, br); err != nil {
+			t.Fatal(err)
+		}
+		streams[bhdr.Name] = data.String()
+	}
+}
+
+type testEntry struct {
+	name string
+	data string
+}
+
+func makeTestTar(t *testing.T, entries []testEntry) io.Reader {
+	b := &bytes.Buffer{}
+	tw := tar.NewWriter(b)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWriteLayerFromTarInterleavedStreams(t *testing.T) {
+	r := makeTestTar(t, []testEntry{
+		{name: "Files/"},
+		{name: "Files/b:s1", data: "stream1"},
+		{name: "Files/a", data: "a"},
+		{name: "Files/a:s2", data: "stream2"},
+		{name: "Files/b", data: "b"},
+	})
+	w := newTestLayerWriter()
+	if _, err := writeLayerFromTar(r, w, t.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if s := w.streams(t, `Files\a`); len(s) != 1 || s[":s2:$DATA"] != "stream2" {
+		t.Fatalf("unexpected streams for a: %v", s)
+	}
+	if s := w.streams(t, `Files\b`); len(s) != 1 || s[":s1:$DATA"] != "stream1" {
+		t.Fatalf("unexpected streams for b: %v", s)
+	}
+}
+
+func TestWriteLayerFromTarDirectoryStreams(t *testing.T) {
+	r := makeTestTar(t, []testEntry{
+		{name: "Files/dir/"},
+		{name: "Files/dir:s1", data: "stream1"},
+		{name: "Files/dir/a", data: "a"},
+	})
+	w := newTestLayerWriter()
+	if _, err := writeLayerFromTar(r, w, t.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if s := w.streams(t, `Files\dir`); len(s) != 1 || s[":s1:$DATA"] != "stream1" {
+		t.Fatalf("unexpected streams for dir: %v", s)
+	}
+	if _, ok := w.files[`Files\dir:s1`]; ok {
+		t.Fatal("directory stream was added as a file")
+	}
+}
+
+func TestWriteLayerFromTarOrphanedStream(t *testing.T) {
+	r := makeTestTar(t, []testEntry{
+		{name: "Files/a", data: "a"},
+		{name: "Files/b:s1", data: "stream1"},
+	})
+	_, err := writeLayerFromTar(r, newTestLayerWriter(), t.Name())
+	if err == nil || !strings.Contains(err.Error(), "has no parent") {
+		t.Fatalf("expected orphaned stream error, got: %v", err)
+	}
+}
+
+func TestWriteLayerFromTarStreamAfterParentWritten(t *testing.T) {
+	r := makeTestTar(t, []testEntry{
+		{name: "Files/a", data: "a"},
+		{name: "Files/b", data: "b"},
+		{name: "Files/a:s1", data: "stream1"},
+	})
+	_, err := writeLayerFromTar(r, newTestLayerWriter(), t.Name())
+	if err == nil || !strings.Contains(err.Error(), "already written") {
+		t.Fatalf("expected stream after parent error, got: %v", err)
+	}
+}
@@ -13,6 +13,9 @@ type Uefi struct {
 
 	EnableDebugger bool `json:"EnableDebugger,omitempty"`
 
+	// ApplySecureBootTemplate is not in the 2.1 schema. If regenerated need to put back.
+	ApplySecureBootTemplate string `json:"ApplySecureBootTemplate,omitempty"`
+
 	SecureBootTemplateId string `json:"SecureBootTemplateId,omitempty"`
 
 	BootThis *UefiBootEntry `json:"BootThis,omitempty"`
@@ -0,0 +1,65 @@
+package uvm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+// comPortPipeSecurityDescriptor allows SYSTEM, administrators and virtual
+// machine worker processes (NT VIRTUAL MACHINE\Virtual Machines) to connect to
+// a COM port pipe created by this process.
+const comPortPipeSecurityDescriptor = "D:P(A;;GA;;;SY)(A;;GA;;;BA)(A;;GA;;;S-1-5-83-0)"
+
+// validateComPorts verifies that only COM1 ("0") and COM2 ("1") are
+// configured, and that COM1 is not also requested for `consoleLogFile`.
+func validateComPorts(comPorts map[string]string, consoleLogFile string) error {
+	for port := range comPorts {
+		if port != "0" && port != "1" {
+			return fmt.Errorf("invalid COM port '%s': must be 0 (COM1) or 1 (COM2)", port)
+		}
+	}
+	if _, ok := comPorts["0"]; ok && consoleLogFile != "" {
+		return fmt.Errorf("COM port 0 cannot be set when a console log file is requested")
+	}
+	return nil
+}
+
+// listenConsoleLog creates a named pipe for COM1 of the utility VM whose output
+// is appended to `logFile` once the utility VM is started. Returns the pipe
+// path to connect COM1 to.
+func (uvm *UtilityVM) listenConsoleLog(logFile string) (string, error) {
+	pipePath := `\\.\pipe\` + uvm.id + `-com1`
+	l, err := winio.ListenPipe(pipePath, &winio.PipeConfig{SecurityDescriptor: comPortPipeSecurityDescriptor})
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on console pipe %s: %s", pipePath, err)
+	}
+	uvm.consoleLogListener = l
+	uvm.consoleLogProcessingDone = make(chan struct{})
+	uvm.consoleLogHandler = appendToFile(uvm.id, logFile)
+	return pipePath, nil
+}
+
+// appendToFile returns an OutputHandler that appends everything read to
+// `path`.
+func appendToFile(vmid, path string) OutputHandler {
+	return func(r io.Reader) {
+		log := logrus.WithFields(logrus.Fields{
+			logfields.UVMID: vmid,
+			"path":          path,
+		})
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.WithError(err).Error("failed to open console log file")
+			return
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			log.WithError(err).Warning("console log copy ended")
+		}
+	}
+}
@@ -48,6 +48,11 @@ type Options struct {
 	// StorageQoSIopsMaximum sets the maximum number of bytes per second. If `0`
 	// will default to the platform default.
 	StorageQoSBandwidthMaximum int32
+
+	// SCSIEventHandler is optionally called when SCSI disks are attached to or
+	// detached from the UVM, or cannot be attached as the SCSI controllers are
+	// exhausted.
+	SCSIEventHandler SCSIEventHandler `json:"-"`
 }
 
 // newDefaultOptions returns the default base options for WCOW and LCOW.
@@ -113,6 +118,11 @@ func (uvm *UtilityVM) Close() (err error) {
 		uvm.outputListener.Close()
 		uvm.outputListener = nil
 	}
+	if uvm.consoleLogListener != nil {
+		close(uvm.consoleLogProcessingDone)
+		uvm.consoleLogListener.Close()
+		uvm.consoleLogListener = nil
+	}
 	return uvm.hcsSystem.Close()
 }
 
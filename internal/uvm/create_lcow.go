@@ -151,6 +151,7 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		owner:               opts.Owner,
 		operatingSystem:     "linux",
 		scsiControllerCount: opts.SCSIControllerCount,
+		scsiEventHandler:    opts.SCSIEventHandler,
 		vpmemMaxCount:       opts.VPMemDeviceCount,
 		vpmemMaxSizeBytes:   opts.VPMemSizeBytes,
 	}
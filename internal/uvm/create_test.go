@@ -23,3 +23,22 @@ func TestCreateWCOWBadLayerFolders(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCreateWCOWBadComPort(t *testing.T) {
+	opts := NewDefaultOptionsWCOW(t.Name(), "")
+	opts.ComPorts = map[string]string{"2": `\\.\pipe\debugpipe`}
+	_, err := CreateWCOW(opts)
+	if err == nil || err.Error() != `invalid COM port '2': must be 0 (COM1) or 1 (COM2)` {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateWCOWComPortAndConsoleLogFile(t *testing.T) {
+	opts := NewDefaultOptionsWCOW(t.Name(), "")
+	opts.ComPorts = map[string]string{"0": `\\.\pipe\debugpipe`}
+	opts.ConsoleLogFile = `c:\console.log`
+	_, err := CreateWCOW(opts)
+	if err == nil || err.Error() != `COM port 0 cannot be set when a console log file is requested` {
+		t.Fatal(err)
+	}
+}
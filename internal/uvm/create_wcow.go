@@ -11,6 +11,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
 	"github.com/Microsoft/hcsshim/internal/uvmfolder"
+	"github.com/Microsoft/hcsshim/internal/wclayer"
 	"github.com/Microsoft/hcsshim/internal/wcow"
 	"github.com/sirupsen/logrus"
 )
@@ -19,9 +20,23 @@ import (
 type OptionsWCOW struct {
 	*Options
 
-	LayerFolders []string // Set of folders for base layers and scratch. Ordered from top most read-only through base read-only layer, followed by scratch
+	LayerFolders   []string          // Set of folders for base layers and scratch. Ordered from top most read-only through base read-only layer, followed by scratch
+	ComPorts       map[string]string // COM port ("0" for COM1, "1" for COM2) to named pipe path. eg \\.\pipe\debugpipe. An empty path adds a disconnected port. Defaults to no COM ports
+	ConsoleLogFile string            // If set, COM1 is connected to a pipe owned by this process and its output is appended to this file. Cannot be combined with ComPorts["0"]
+
+	SecureBootTemplateID string // UEFI secure boot template to boot the utility VM with. eg `SecureBootTemplateMicrosoftWindows`. Defaults to secure boot disabled
+	GuestStateFile       string // Path to an existing VMGS file for persistent guest state. Defaults to transient in-memory guest state
 }
 
+const (
+	// SecureBootTemplateMicrosoftWindows is the UEFI secure boot template
+	// trusting the Microsoft Windows production CA.
+	SecureBootTemplateMicrosoftWindows = "1734c6e8-3154-4dda-ba5f-a874cc483422"
+	// SecureBootTemplateMicrosoftUEFICertificateAuthority is the UEFI secure
+	// boot template trusting the Microsoft UEFI CA.
+	SecureBootTemplateMicrosoftUEFICertificateAuthority = "272e7447-90a4-4563-a4b9-8e4ab00526ce"
+)
+
 // NewDefaultOptionsWCOW creates the default options for a bootable version of
 // WCOW. The caller `MUST` set the `LayerFolders` path on the returned value.
 //
@@ -56,17 +71,23 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 	}()
 
 	uvm := &UtilityVM{
-		id:                  opts.ID,
-		owner:               opts.Owner,
-		operatingSystem:     "windows",
-		scsiControllerCount: 1,
-		vsmbShares:          make(map[string]*vsmbShare),
+		id:                        opts.ID,
+		owner:                     opts.Owner,
+		operatingSystem:           "windows",
+		scsiControllerCount:       1,
+		scsiEventHandler:          opts.SCSIEventHandler,
+		vsmbShares:                make(map[string]*vsmbShare),
+		vsmbDirectFileMappingInMB: 1024, // Sensible default, but could be a tuning parameter somewhere
 	}
 
 	// To maintain compatability with Docker we need to automatically downgrade
 	// a user CPU count if the setting is not possible.
 	uvm.normalizeProcessorCount(opts.ProcessorCount)
 
+	if err := validateComPorts(opts.ComPorts, opts.ConsoleLogFile); err != nil {
+		return nil, err
+	}
+
 	if len(opts.LayerFolders) < 2 {
 		return nil, fmt.Errorf("at least 2 LayerFolders must be supplied")
 	}
@@ -148,7 +169,7 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 					},
 				},
 				VirtualSmb: &hcsschema.VirtualSmb{
-					DirectFileMappingInMB: 1024, // Sensible default, but could be a tuning parameter somewhere
+					DirectFileMappingInMB: uvm.vsmbDirectFileMappingInMB,
 					Shares: []hcsschema.VirtualSmbShare{
 						{
 							Name: "os",
@@ -175,6 +196,47 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 		}
 	}
 
+	if opts.SecureBootTemplateID != "" {
+		doc.VirtualMachine.Chipset.Uefi.ApplySecureBootTemplate = "Apply"
+		doc.VirtualMachine.Chipset.Uefi.SecureBootTemplateId = opts.SecureBootTemplateID
+	}
+
+	if opts.GuestStateFile != "" {
+		if _, err := os.Stat(opts.GuestStateFile); err != nil {
+			return nil, fmt.Errorf("failed to stat guest state file: %s", err)
+		}
+		// Note: There is no API to revoke this grant, so it is left in place
+		// if creating the compute system fails, as is the grant on the scratch
+		// made by wcow.CreateUVMScratch. It only applies to this VM's ID.
+		if err := wclayer.GrantVmAccess(uvm.id, opts.GuestStateFile); err != nil {
+			return nil, err
+		}
+		doc.VirtualMachine.GuestState = &hcsschema.GuestState{
+			GuestStateFilePath: opts.GuestStateFile,
+		}
+	}
+
+	comPorts := make(map[string]hcsschema.ComPort)
+	for port, pipe := range opts.ComPorts {
+		comPorts[port] = hcsschema.ComPort{NamedPipe: pipe}
+	}
+	if opts.ConsoleLogFile != "" {
+		var pipe string
+		pipe, err = uvm.listenConsoleLog(opts.ConsoleLogFile)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				uvm.consoleLogListener.Close()
+			}
+		}()
+		comPorts["0"] = hcsschema.ComPort{NamedPipe: pipe} // Which is actually COM1
+	}
+	if len(comPorts) > 0 {
+		doc.VirtualMachine.Devices.ComPorts = comPorts
+	}
+
 	uvm.scsiLocations[0][0].hostPath = doc.VirtualMachine.Devices.Scsi["0"].Attachments["0"].Path
 
 	fullDoc, err := mergemaps.MergeJSON(doc, ([]byte)(opts.AdditionHCSDocumentJSON))
@@ -18,6 +18,76 @@ var (
 	ErrNoSCSIControllers        = fmt.Errorf("no SCSI controllers configured for this utility VM")
 	ErrTooManyAttachments       = fmt.Errorf("too many SCSI attachments")
 	ErrSCSILayerWCOWUnsupported = fmt.Errorf("SCSI attached layers are not supported for WCOW")
+	ErrInvalidSCSICachingMode   = fmt.Errorf("invalid SCSI caching mode")
+)
+
+// SCSIExhaustedError is returned when a disk cannot be attached to a utility
+// VM because all usable SCSI locations are in use. It carries the SCSI usage of
+// the utility VM at the time of the failure so that callers can report it or
+// back off.
+type SCSIExhaustedError struct {
+	HostPath    string
+	Err         error                 // ErrTooManyAttachments or ErrNoAvailableLocation
+	Controllers []SCSIControllerStats // Usage of each configured SCSI controller
+}
+
+func (e *SCSIExhaustedError) Error() string {
+	s := fmt.Sprintf("failed to attach %s: %s", e.HostPath, e.Err)
+	for i, c := range e.Controllers {
+		s += fmt.Sprintf(" (controller %d: %d used, %d free)", i, c.UsedLUNs, c.FreeLUNs)
+	}
+	return s
+}
+
+// IsSCSIExhausted returns true when `err` is caused by the utility VM having no
+// SCSI location available for a new attachment.
+func IsSCSIExhausted(err error) bool {
+	_, ok := err.(*SCSIExhaustedError)
+	return ok
+}
+
+// SCSIEventType identifies a change to the SCSI attachments of a utility VM.
+type SCSIEventType string
+
+const (
+	SCSIEventAdd       SCSIEventType = "Add"       // A disk was attached
+	SCSIEventRemove    SCSIEventType = "Remove"    // A disk was detached
+	SCSIEventExhausted SCSIEventType = "Exhausted" // A disk could not be attached as no SCSI location is available
+)
+
+// SCSIEvent is passed to the SCSIEventHandler of a utility VM.
+type SCSIEvent struct {
+	Type       SCSIEventType
+	HostPath   string
+	Controller int   // -1 for SCSIEventExhausted
+	LUN        int32 // -1 for SCSIEventExhausted
+	Err        error // The *SCSIExhaustedError for SCSIEventExhausted, otherwise nil
+}
+
+// SCSIEventHandler is called when a disk is attached to or detached from a
+// utility VM, and when an attachment fails because the SCSI controllers are
+// exhausted. Further references to an already attached layer, and the release
+// of all but the last reference, do not raise an event.
+//
+// It is called without the utility VM lock held, from the goroutine
+// performing the operation, and so should not block.
+type SCSIEventHandler func(SCSIEvent)
+
+// notifySCSI calls the SCSIEventHandler of the utility VM, if any, with `e`.
+// The lock must not be held when calling this function.
+func (uvm *UtilityVM) notifySCSI(e SCSIEvent) {
+	if uvm.scsiEventHandler != nil {
+		uvm.scsiEventHandler(e)
+	}
+}
+
+// Caching modes that may be requested for a SCSI attachment via
+// AddSCSIWithCachingMode or AddSCSILayerWithCachingMode. An empty caching mode
+// leaves the platform default.
+const (
+	SCSICachingModeUncached       = "Uncached"
+	SCSICachingModeCached         = "Cached"
+	SCSICachingModeReadOnlyCached = "ReadOnlyCached"
 )
 
 // allocateSCSI finds the next available slot on the
@@ -117,7 +187,55 @@ func (uvm *UtilityVM) AddSCSI(hostPath string, uvmPath string, readOnly bool) (_
 		}
 	}()
 
-	return uvm.addSCSIActual(hostPath, uvmPath, "VirtualDisk", false, readOnly)
+	return uvm.addSCSIActual(hostPath, uvmPath, "VirtualDisk", "", false, readOnly)
+}
+
+// validateSCSICachingMode returns `ErrInvalidSCSICachingMode` if `cachingMode`
+// is not empty or one of the `SCSICachingMode*` constants, or if it is
+// `SCSICachingModeReadOnlyCached` for a writable attachment.
+func validateSCSICachingMode(cachingMode string, readOnly bool) error {
+	switch cachingMode {
+	case "", SCSICachingModeUncached, SCSICachingModeCached:
+		return nil
+	case SCSICachingModeReadOnlyCached:
+		if readOnly {
+			return nil
+		}
+	}
+	return ErrInvalidSCSICachingMode
+}
+
+// AddSCSIWithCachingMode is the same as AddSCSI but additionally sets the host
+// caching mode used for the attachment. For read-only LCOW layers use
+// AddSCSILayerWithCachingMode instead.
+//
+// `cachingMode` is empty for the platform default or one of the
+// `SCSICachingMode*` constants. `SCSICachingModeReadOnlyCached` requires
+// `readOnly` to be `true`. Otherwise `ErrInvalidSCSICachingMode` is returned.
+func (uvm *UtilityVM) AddSCSIWithCachingMode(hostPath, uvmPath string, readOnly bool, cachingMode string) (_ int, _ int32, err error) {
+	op := "uvm::AddSCSIWithCachingMode"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+		"uvm-path":      uvmPath,
+		"readOnly":      readOnly,
+		"cachingMode":   cachingMode,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if err := validateSCSICachingMode(cachingMode, readOnly); err != nil {
+		return -1, -1, err
+	}
+
+	return uvm.addSCSIActual(hostPath, uvmPath, "VirtualDisk", cachingMode, false, readOnly)
 }
 
 // AddSCSIPhysicalDisk attaches a physical disk from the host directly to the
@@ -146,7 +264,7 @@ func (uvm *UtilityVM) AddSCSIPhysicalDisk(hostPath, uvmPath string, readOnly boo
 		}
 	}()
 
-	return uvm.addSCSIActual(hostPath, uvmPath, "PassThru", false, readOnly)
+	return uvm.addSCSIActual(hostPath, uvmPath, "PassThru", "", false, readOnly)
 }
 
 // AddSCSILayer adds a read-only layer disk to a utility VM at the next available
@@ -172,11 +290,49 @@ func (uvm *UtilityVM) AddSCSILayer(hostPath string) (_ int, _ int32, err error)
 		return -1, -1, ErrSCSILayerWCOWUnsupported
 	}
 
-	return uvm.addSCSIActual(hostPath, "", "VirtualDisk", true, true)
+	return uvm.addSCSIActual(hostPath, "", "VirtualDisk", "", true, true)
+}
+
+// AddSCSILayerWithCachingMode is the same as AddSCSILayer but additionally sets
+// the host caching mode used for the attachment, for example
+// `SCSICachingModeReadOnlyCached` to cache read-only layer VHDs on the host.
+//
+// The caching mode only applies when the layer is first attached. Further
+// references to an already attached layer keep its existing caching mode.
+//
+// `cachingMode` is empty for the platform default or one of the
+// `SCSICachingMode*` constants. Otherwise `ErrInvalidSCSICachingMode` is
+// returned.
+func (uvm *UtilityVM) AddSCSILayerWithCachingMode(hostPath, cachingMode string) (_ int, _ int32, err error) {
+	op := "uvm::AddSCSILayerWithCachingMode"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+		"cachingMode":   cachingMode,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if uvm.operatingSystem == "windows" {
+		return -1, -1, ErrSCSILayerWCOWUnsupported
+	}
+	if err := validateSCSICachingMode(cachingMode, true); err != nil {
+		return -1, -1, err
+	}
+
+	return uvm.addSCSIActual(hostPath, "", "VirtualDisk", cachingMode, true, true)
 }
 
-// addSCSIActual is the implementation behind the external functions AddSCSI and
-// AddSCSILayer.
+// addSCSIActual is the implementation behind the external functions AddSCSI,
+// AddSCSIWithCachingMode, AddSCSIPhysicalDisk, AddSCSILayer and
+// AddSCSILayerWithCachingMode.
 //
 // We are in control of everything ourselves. Hence we have ref- counting and
 // so-on tracking what SCSI locations are available or used.
@@ -189,13 +345,15 @@ func (uvm *UtilityVM) AddSCSILayer(hostPath string) (_ int, _ int32, err error)
 // `attachmentType` is required and `must` be `VirtualDisk` for vhd/vhdx
 // attachments and `PassThru` for physical disk.
 //
+// `cachingMode` is optional. If empty the platform default caching is used.
+//
 // `isLayer` indicates that this is a read-only (LCOW) layer VHD. This parameter
 // `must not` be used for Windows.
 //
 // `readOnly` indicates the attachment should be added read only.
 //
 // Returns the controller ID (0..3) and LUN (0..63) where the disk is attached.
-func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, isLayer, readOnly bool) (int, int32, error) {
+func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType, cachingMode string, isLayer, readOnly bool) (int, int32, error) {
 	if uvm.scsiControllerCount == 0 {
 		return -1, -1, ErrNoSCSIControllers
 	}
@@ -230,7 +388,13 @@ func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, is
 	// ref-counted layer VHD, or not.
 	controller, lun, err := uvm.allocateSCSI(hostPath, uvmPath, isLayer)
 	if err != nil {
+		err = &SCSIExhaustedError{
+			HostPath:    hostPath,
+			Err:         err,
+			Controllers: uvm.statsLocked().SCSIControllers,
+		}
 		uvm.m.Unlock()
+		uvm.notifySCSI(SCSIEvent{Type: SCSIEventExhausted, HostPath: hostPath, Controller: -1, LUN: -1, Err: err})
 		return -1, -1, err
 	}
 
@@ -245,15 +409,22 @@ func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, is
 	// Note: Can remove this check post-RS5 if multiple controllers are supported
 	if controller > 0 {
 		uvm.deallocateSCSI(controller, lun)
-		return -1, -1, ErrTooManyAttachments
+		err := &SCSIExhaustedError{
+			HostPath:    hostPath,
+			Err:         ErrTooManyAttachments,
+			Controllers: uvm.Stats().SCSIControllers,
+		}
+		uvm.notifySCSI(SCSIEvent{Type: SCSIEventExhausted, HostPath: hostPath, Controller: -1, LUN: -1, Err: err})
+		return -1, -1, err
 	}
 
 	SCSIModification := &hcsschema.ModifySettingRequest{
 		RequestType: requesttype.Add,
 		Settings: hcsschema.Attachment{
-			Path:     hostPath,
-			Type_:    attachmentType,
-			ReadOnly: readOnly,
+			Path:        hostPath,
+			Type_:       attachmentType,
+			CachingMode: cachingMode,
+			ReadOnly:    readOnly,
 		},
 		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/Scsi/%d/Attachments/%d", controller, lun),
 	}
@@ -286,6 +457,7 @@ func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, is
 		uvm.deallocateSCSI(controller, lun)
 		return -1, -1, fmt.Errorf("uvm::AddSCSI: failed to modify utility VM configuration: %s", err)
 	}
+	uvm.notifySCSI(SCSIEvent{Type: SCSIEventAdd, HostPath: hostPath, Controller: controller, LUN: lun})
 	return controller, lun, nil
 
 }
@@ -308,6 +480,15 @@ func (uvm *UtilityVM) RemoveSCSI(hostPath string) (err error) {
 		}
 	}()
 
+	// Registered before the unlock below so that the event is raised after the
+	// lock is released.
+	var removed SCSIEvent
+	defer func() {
+		if removed.Type != "" {
+			uvm.notifySCSI(removed)
+		}
+	}()
+
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
 
@@ -332,6 +513,7 @@ func (uvm *UtilityVM) RemoveSCSI(hostPath string) (err error) {
 		return fmt.Errorf("failed to remove SCSI disk %s from container %s: %s", hostPath, uvm.id, err)
 
 	}
+	removed = SCSIEvent{Type: SCSIEventRemove, HostPath: hostPath, Controller: controller, LUN: lun}
 	return nil
 }
 
@@ -0,0 +1,117 @@
+package uvm
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAddSCSIExhausted(t *testing.T) {
+	u := &UtilityVM{
+		id:                  t.Name(),
+		operatingSystem:     "linux",
+		scsiControllerCount: 1,
+	}
+	for lun := range u.scsiLocations[0] {
+		u.scsiLocations[0][lun].hostPath = fmt.Sprintf(`c:\disk%d.vhdx`, lun)
+	}
+
+	_, _, err := u.addSCSIActual(`c:\layer.vhd`, "", "VirtualDisk", "", true, true)
+	if !IsSCSIExhausted(err) {
+		t.Fatalf("expected SCSI exhausted error, got: %v", err)
+	}
+	serr := err.(*SCSIExhaustedError)
+	if serr.Err != ErrTooManyAttachments {
+		t.Fatalf("expected inner error %v, got: %v", ErrTooManyAttachments, serr.Err)
+	}
+	if len(serr.Controllers) != 1 || serr.Controllers[0].UsedLUNs != 64 || serr.Controllers[0].FreeLUNs != 0 {
+		t.Fatalf("unexpected controller stats: %+v", serr.Controllers)
+	}
+
+	// The failed attachment must not leave anything allocated.
+	if _, _, _, err := u.findSCSIAttachment(`c:\layer.vhd`); err != ErrNotAttached {
+		t.Fatalf("expected %v, got: %v", ErrNotAttached, err)
+	}
+}
+
+func TestStatsSCSIControllers(t *testing.T) {
+	u := &UtilityVM{
+		id:                  t.Name(),
+		scsiControllerCount: 1,
+	}
+	u.scsiLocations[0][0].hostPath = `c:\sandbox.vhdx`
+	u.scsiLocations[0][5].hostPath = `c:\data.vhdx`
+
+	s := u.Stats()
+	if len(s.SCSIControllers) != 1 {
+		t.Fatalf("expected 1 controller, got: %d", len(s.SCSIControllers))
+	}
+	if s.SCSIControllers[0].UsedLUNs != 2 || s.SCSIControllers[0].FreeLUNs != 62 {
+		t.Fatalf("unexpected controller stats: %+v", s.SCSIControllers[0])
+	}
+}
+
+func TestAddSCSICachingModeValidation(t *testing.T) {
+	u := &UtilityVM{
+		id:                  t.Name(),
+		operatingSystem:     "linux",
+		scsiControllerCount: 1,
+	}
+
+	for _, mode := range []string{"Bogus", "cached"} {
+		if _, _, err := u.AddSCSIWithCachingMode(`c:\disk.vhdx`, "", true, mode); err != ErrInvalidSCSICachingMode {
+			t.Fatalf("mode %q: expected %v, got: %v", mode, ErrInvalidSCSICachingMode, err)
+		}
+		if _, _, err := u.AddSCSILayerWithCachingMode(`c:\layer.vhd`, mode); err != ErrInvalidSCSICachingMode {
+			t.Fatalf("layer mode %q: expected %v, got: %v", mode, ErrInvalidSCSICachingMode, err)
+		}
+	}
+
+	// ReadOnlyCached is only valid for read only attachments.
+	if _, _, err := u.AddSCSIWithCachingMode(`c:\disk.vhdx`, "", false, SCSICachingModeReadOnlyCached); err != ErrInvalidSCSICachingMode {
+		t.Fatalf("expected %v, got: %v", ErrInvalidSCSICachingMode, err)
+	}
+
+	// Valid modes, including the empty platform default, pass validation and
+	// fail later only because there is no free SCSI location.
+	for lun := range u.scsiLocations[0] {
+		u.scsiLocations[0][lun].hostPath = fmt.Sprintf(`c:\disk%d.vhdx`, lun)
+	}
+	for _, mode := range []string{"", SCSICachingModeUncached, SCSICachingModeCached, SCSICachingModeReadOnlyCached} {
+		if _, _, err := u.AddSCSILayerWithCachingMode(`c:\layer.vhd`, mode); !IsSCSIExhausted(err) {
+			t.Fatalf("mode %q: expected SCSI exhausted error, got: %v", mode, err)
+		}
+	}
+}
+
+func TestAddSCSIExhaustedEvent(t *testing.T) {
+	var events []SCSIEvent
+	u := &UtilityVM{
+		id:                  t.Name(),
+		operatingSystem:     "linux",
+		scsiControllerCount: 1,
+		scsiEventHandler: func(e SCSIEvent) {
+			events = append(events, e)
+		},
+	}
+	for lun := range u.scsiLocations[0] {
+		u.scsiLocations[0][lun].hostPath = fmt.Sprintf(`c:\disk%d.vhdx`, lun)
+	}
+
+	_, _, err := u.addSCSIActual(`c:\layer.vhd`, "", "VirtualDisk", "", true, true)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got: %+v", events)
+	}
+	e := events[0]
+	if e.Type != SCSIEventExhausted || e.HostPath != `c:\layer.vhd` || e.Controller != -1 || e.LUN != -1 || e.Err != err {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+
+	// A further reference to an attached layer raises no event.
+	u.scsiLocations[0][1] = scsiInfo{hostPath: `c:\shared.vhd`, isLayer: true, refCount: 1}
+	if _, _, err := u.addSCSIActual(`c:\shared.vhd`, "", "VirtualDisk", "", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected no further events, got: %+v", events[1:])
+	}
+}
@@ -145,5 +145,11 @@ func (uvm *UtilityVM) Start() (err error) {
 		uvm.outputProcessingCancel = cancel
 		uvm.outputListener = nil
 	}
+	if uvm.consoleLogListener != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go processOutput(ctx, uvm.consoleLogListener, uvm.consoleLogProcessingDone, uvm.consoleLogHandler)
+		uvm.consoleLogProcessingCancel = cancel
+		uvm.consoleLogListener = nil
+	}
 	return uvm.hcsSystem.Start()
 }
@@ -0,0 +1,60 @@
+package uvm
+
+// Stats is a point-in-time snapshot of the host resources a utility VM is
+// consuming. It is intended for diagnostics, for example to understand why
+// adding a further VSMB share started failing in a dense utility VM.
+type Stats struct {
+	// VSMBShares is the number of VSMB shares currently mapped into the
+	// utility VM, excluding the boot share.
+	VSMBShares int
+	// VSMBSharesAdded is the total number of VSMB shares added to the utility
+	// VM over its lifetime.
+	VSMBSharesAdded uint64
+	// VSMBSharesRemoved is the total number of VSMB shares removed from the
+	// utility VM over its lifetime.
+	VSMBSharesRemoved uint64
+	// VSMBDirectFileMappingInMB is the direct file mapping budget the utility
+	// VM was configured with. `0` if VSMB is not used by the utility VM.
+	VSMBDirectFileMappingInMB int64
+
+	// SCSIControllers is the usage of each SCSI controller configured on the
+	// utility VM, indexed by controller number.
+	SCSIControllers []SCSIControllerStats
+}
+
+// SCSIControllerStats describes the usage of a single SCSI controller of a
+// utility VM.
+type SCSIControllerStats struct {
+	UsedLUNs int
+	FreeLUNs int
+}
+
+// Stats returns a snapshot of the resource usage of the utility VM.
+func (uvm *UtilityVM) Stats() Stats {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	return uvm.statsLocked()
+}
+
+// statsLocked is the implementation of Stats. The mutex MUST be held when
+// calling this function.
+func (uvm *UtilityVM) statsLocked() Stats {
+	s := Stats{
+		VSMBShares:                len(uvm.vsmbShares),
+		VSMBSharesAdded:           uvm.vsmbSharesAdded,
+		VSMBSharesRemoved:         uvm.vsmbSharesRemoved,
+		VSMBDirectFileMappingInMB: uvm.vsmbDirectFileMappingInMB,
+	}
+	for controller := 0; controller < int(uvm.scsiControllerCount); controller++ {
+		var c SCSIControllerStats
+		for _, si := range uvm.scsiLocations[controller] {
+			if si.hostPath != "" {
+				c.UsedLUNs++
+			} else {
+				c.FreeLUNs++
+			}
+		}
+		s.SCSIControllers = append(s.SCSIControllers, c)
+	}
+	return s
+}
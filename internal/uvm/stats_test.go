@@ -0,0 +1,33 @@
+package uvm
+
+import (
+	"testing"
+)
+
+func TestStatsVSMB(t *testing.T) {
+	u := &UtilityVM{
+		id:              t.Name(),
+		operatingSystem: "windows",
+		vsmbShares: map[string]*vsmbShare{
+			`c:\layer1`: {refCount: 1, name: "s3"},
+			`c:\layer2`: {refCount: 2, name: "s4"},
+		},
+		vsmbSharesAdded:           4,
+		vsmbSharesRemoved:         2,
+		vsmbDirectFileMappingInMB: 1024,
+	}
+
+	s := u.Stats()
+	if s.VSMBShares != 2 {
+		t.Fatalf("expected 2 VSMB shares, got: %d", s.VSMBShares)
+	}
+	if s.VSMBSharesAdded != 4 || s.VSMBSharesRemoved != 2 {
+		t.Fatalf("unexpected VSMB share counters: added %d, removed %d", s.VSMBSharesAdded, s.VSMBSharesRemoved)
+	}
+	if s.VSMBDirectFileMappingInMB != 1024 {
+		t.Fatalf("expected 1024MB direct file mapping, got: %d", s.VSMBDirectFileMappingInMB)
+	}
+	if len(s.SCSIControllers) != 0 {
+		t.Fatalf("expected no SCSI controllers, got: %+v", s.SCSIControllers)
+	}
+}
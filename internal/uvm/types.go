@@ -10,6 +10,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/guid"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hns"
+	"github.com/Microsoft/hcsshim/internal/schema2"
 )
 
 //                    | WCOW | LCOW
@@ -23,6 +24,7 @@ type vsmbShare struct {
 	refCount     uint32
 	name         string
 	guestRequest interface{}
+	options      hcsschema.VirtualSmbShareOptions
 }
 
 // scsiInfo is an internal structure used for determining what is mapped to a utility VM.
@@ -75,6 +77,10 @@ type UtilityVM struct {
 	vsmbShares  map[string]*vsmbShare
 	vsmbCounter uint64 // Counter to generate a unique share name for each VSMB share.
 
+	vsmbSharesAdded           uint64 // Total VSMB shares added over the lifetime of the UVM
+	vsmbSharesRemoved         uint64 // Total VSMB shares removed over the lifetime of the UVM
+	vsmbDirectFileMappingInMB int64  // Direct file mapping budget the UVM was created with
+
 	// VPMEM devices that are mapped into a Linux UVM. These are used for read-only layers, or for
 	// booting from VHD.
 	vpmemDevices      [MaxVPMEMCount]vpmemInfo // Limited by ACPI size.
@@ -82,8 +88,9 @@ type UtilityVM struct {
 	vpmemMaxSizeBytes uint64                   // Actual size of VPMem devices
 
 	// SCSI devices that are mapped into a Windows or Linux utility VM
-	scsiLocations       [4][64]scsiInfo // Hyper-V supports 4 controllers, 64 slots per controller. Limited to 1 controller for now though.
-	scsiControllerCount uint32          // Number of SCSI controllers in the utility VM
+	scsiLocations       [4][64]scsiInfo  // Hyper-V supports 4 controllers, 64 slots per controller. Limited to 1 controller for now though.
+	scsiControllerCount uint32           // Number of SCSI controllers in the utility VM
+	scsiEventHandler    SCSIEventHandler // Optional, see Options.SCSIEventHandler
 
 	// Plan9 are directories mapped into a Linux utility VM
 	plan9Counter uint64 // Each newly-added plan9 share has a counter used as its ID in the ResourceURI and for the name
@@ -94,4 +101,11 @@ type UtilityVM struct {
 	outputProcessingDone   chan struct{}
 	outputHandler          OutputHandler
 	outputProcessingCancel context.CancelFunc
+
+	// consoleLog* capture COM1 of a WCOW utility VM to a file, see
+	// OptionsWCOW.ConsoleLogFile.
+	consoleLogListener         net.Listener
+	consoleLogProcessingDone   chan struct{}
+	consoleLogHandler          OutputHandler
+	consoleLogProcessingCancel context.CancelFunc
 }
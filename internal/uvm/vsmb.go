@@ -10,6 +10,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrVSMBShareInUse is returned when modifying a VSMB share that is referenced
+// by more than one user.
+var ErrVSMBShareInUse = fmt.Errorf("VSMB share is in use by more than one user")
+
 // findVSMBShare finds a share by `hostPath`. If not found returns `ErrNotAttached`.
 func (uvm *UtilityVM) findVSMBShare(hostPath string) (*vsmbShare, error) {
 	share, ok := uvm.vsmbShares[hostPath]
@@ -32,6 +36,9 @@ func (share *vsmbShare) GuestPath() string {
 // AddVSMB adds a VSMB share to a Windows utility VM. Each VSMB share is ref-counted and
 // only added if it isn't already. This is used for read-only layers, mapped directories
 // to a container, and for mapped pipes.
+//
+// If `hostPath` is already shared, `options` are ignored and the share keeps the
+// options it was first added with. Use VSMBShareOptions to query them.
 func (uvm *UtilityVM) AddVSMB(hostPath string, guestRequest interface{}, options *hcsschema.VirtualSmbShareOptions) (err error) {
 	op := "uvm::AddVSMB"
 	log := logrus.WithFields(logrus.Fields{
@@ -70,12 +77,17 @@ func (uvm *UtilityVM) AddVSMB(hostPath string, guestRequest interface{}, options
 		}
 
 		if err := uvm.Modify(modification); err != nil {
+			log.Data["stats"] = uvm.statsLocked()
 			return err
 		}
+		uvm.vsmbSharesAdded++
 		share = &vsmbShare{
 			name:         shareName,
 			guestRequest: guestRequest,
 		}
+		if options != nil {
+			share.options = *options
+		}
 		uvm.vsmbShares[hostPath] = share
 	}
 	share.refCount++
@@ -126,9 +138,121 @@ func (uvm *UtilityVM) RemoveVSMB(hostPath string) (err error) {
 	}
 
 	delete(uvm.vsmbShares, hostPath)
+	uvm.vsmbSharesRemoved++
 	return nil
 }
 
+// VSMBShareOptions returns the options the VSMB share for `hostPath` currently
+// has. If `hostPath` is not shared returns `ErrNotAttached`.
+func (uvm *UtilityVM) VSMBShareOptions(hostPath string) (_ *hcsschema.VirtualSmbShareOptions, err error) {
+	op := "uvm::VSMBShareOptions"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if uvm.operatingSystem != "windows" {
+		return nil, errNotSupported
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	share, err := uvm.findVSMBShare(hostPath)
+	if err != nil {
+		return nil, err
+	}
+	options := share.options
+	return &options, nil
+}
+
+// ModifyVSMBShareOptions changes the options of an existing VSMB share in
+// place, avoiding a remove and re-add of the share. The host must support
+// updating VSMB shares for this to succeed.
+//
+// As the options apply to every user of the share, a share with more than one
+// reference may only be relaxed, see isVSMBOptionsRelaxation. Any other change
+// to such a share fails with `ErrVSMBShareInUse`. A share with a single
+// reference may be changed freely. Unchanged options are a no-op. Comparisons
+// are made against the options the share actually has, see AddVSMB. If
+// `hostPath` is not shared returns `ErrNotAttached`.
+func (uvm *UtilityVM) ModifyVSMBShareOptions(hostPath string, options *hcsschema.VirtualSmbShareOptions) (err error) {
+	op := "uvm::ModifyVSMBShareOptions"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+	})
+	log.Debugf(op+" - Options: %+v - Begin Operation", options)
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if uvm.operatingSystem != "windows" {
+		return errNotSupported
+	}
+	if options == nil {
+		return fmt.Errorf("no options passed to ModifyVSMBShareOptions")
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	share, err := uvm.findVSMBShare(hostPath)
+	if err != nil {
+		return err
+	}
+	if share.options == *options {
+		return nil
+	}
+	if share.refCount > 1 && !isVSMBOptionsRelaxation(share.options, *options) {
+		return ErrVSMBShareInUse
+	}
+
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType: requesttype.Update,
+		Settings: hcsschema.VirtualSmbShare{
+			Name:    share.name,
+			Options: options,
+			Path:    hostPath,
+		},
+		ResourcePath: "VirtualMachine/Devices/VirtualSmb/Shares",
+	}
+	if err := uvm.Modify(modification); err != nil {
+		return fmt.Errorf("failed to update vsmb share %s in %s: %+v: %s", hostPath, uvm.id, modification, err)
+	}
+	share.options = *options
+	return nil
+}
+
+// isVSMBOptionsRelaxation returns true if `options` differ from `current` only
+// by enabling direct mapping (clearing NoDirectmap) or directory change
+// notifications (clearing NoDirnotify). These add a capability without taking
+// away anything other users of the share rely on, so are safe to apply to a
+// share with more than one reference. Every other option, such as ReadOnly or
+// the caching and oplock behaviour, must be unchanged.
+func isVSMBOptionsRelaxation(current, options hcsschema.VirtualSmbShareOptions) bool {
+	relaxed := current
+	if !options.NoDirectmap {
+		relaxed.NoDirectmap = false
+	}
+	if !options.NoDirnotify {
+		relaxed.NoDirnotify = false
+	}
+	return relaxed == options
+}
+
 // GetVSMBUvmPath returns the guest path of a VSMB mount.
 func (uvm *UtilityVM) GetVSMBUvmPath(hostPath string) (_ string, err error) {
 	op := "uvm::GetVSMBUvmPath"
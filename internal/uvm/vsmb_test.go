@@ -0,0 +1,120 @@
+package uvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+func newTestVSMBUtilityVM(t *testing.T) *UtilityVM {
+	return &UtilityVM{
+		id:              t.Name(),
+		operatingSystem: "windows",
+		vsmbShares: map[string]*vsmbShare{
+			`c:\layer`: {
+				refCount: 1,
+				name:     "s1",
+				options:  hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true},
+			},
+		},
+	}
+}
+
+func TestVSMBShareOptions(t *testing.T) {
+	u := newTestVSMBUtilityVM(t)
+
+	if _, err := u.VSMBShareOptions(`c:\missing`); err != ErrNotAttached {
+		t.Fatalf("expected %v, got: %v", ErrNotAttached, err)
+	}
+
+	options, err := u.VSMBShareOptions(`c:\layer`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *options != (hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true}) {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+
+	// The returned options must be a copy.
+	options.NoDirectmap = true
+	if u.vsmbShares[`c:\layer`].options.NoDirectmap {
+		t.Fatal("modifying the returned options changed the share")
+	}
+}
+
+func TestModifyVSMBShareOptions(t *testing.T) {
+	u := newTestVSMBUtilityVM(t)
+
+	changed := &hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true, NoDirectmap: true}
+	if err := u.ModifyVSMBShareOptions(`c:\missing`, changed); err != ErrNotAttached {
+		t.Fatalf("expected %v, got: %v", ErrNotAttached, err)
+	}
+
+	// Unchanged options are a no-op and do not need the compute system.
+	unchanged := &hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true}
+	if err := u.ModifyVSMBShareOptions(`c:\layer`, unchanged); err != nil {
+		t.Fatal(err)
+	}
+
+	u.vsmbShares[`c:\layer`].refCount = 2
+	if err := u.ModifyVSMBShareOptions(`c:\layer`, changed); err != ErrVSMBShareInUse {
+		t.Fatalf("expected %v, got: %v", ErrVSMBShareInUse, err)
+	}
+	if u.vsmbShares[`c:\layer`].options.NoDirectmap {
+		t.Fatal("share options changed although the share is in use")
+	}
+}
+
+func TestModifyVSMBShareOptionsIssuesUpdate(t *testing.T) {
+	u := newTestVSMBUtilityVM(t)
+	// A compute system that was never opened fails every modify request
+	// without calling HCS, which shows the update was attempted.
+	u.hcsSystem = &hcs.System{}
+
+	// Any change is attempted for a share with a single reference.
+	changed := &hcsschema.VirtualSmbShareOptions{ReadOnly: false, CacheIo: true}
+	err := u.ModifyVSMBShareOptions(`c:\layer`, changed)
+	if err == nil || !strings.Contains(err.Error(), "failed to update vsmb share") {
+		t.Fatalf("expected update to be attempted, got: %v", err)
+	}
+
+	// Relaxations are attempted for a shared share.
+	u.vsmbShares[`c:\layer`].refCount = 2
+	u.vsmbShares[`c:\layer`].options.NoDirectmap = true
+	relaxed := &hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true}
+	err = u.ModifyVSMBShareOptions(`c:\layer`, relaxed)
+	if err == nil || !strings.Contains(err.Error(), "failed to update vsmb share") {
+		t.Fatalf("expected update to be attempted, got: %v", err)
+	}
+
+	// A failed update leaves the recorded options alone.
+	if !u.vsmbShares[`c:\layer`].options.NoDirectmap {
+		t.Fatal("share options changed although the update failed")
+	}
+}
+
+func TestIsVSMBOptionsRelaxation(t *testing.T) {
+	current := hcsschema.VirtualSmbShareOptions{ReadOnly: true, NoDirectmap: true, NoDirnotify: true}
+	for _, tc := range []struct {
+		options hcsschema.VirtualSmbShareOptions
+		relaxed bool
+	}{
+		{hcsschema.VirtualSmbShareOptions{ReadOnly: true, NoDirnotify: true}, true},
+		{hcsschema.VirtualSmbShareOptions{ReadOnly: true, NoDirectmap: true}, true},
+		{hcsschema.VirtualSmbShareOptions{ReadOnly: true}, true},
+		{hcsschema.VirtualSmbShareOptions{NoDirectmap: true, NoDirnotify: true}, false},
+		{hcsschema.VirtualSmbShareOptions{ReadOnly: true, CacheIo: true}, false},
+		{hcsschema.VirtualSmbShareOptions{ReadOnly: true, NoDirectmap: true, NoDirnotify: true, NoOplocks: true}, false},
+	} {
+		if got := isVSMBOptionsRelaxation(current, tc.options); got != tc.relaxed {
+			t.Fatalf("%+v: expected %v, got %v", tc.options, tc.relaxed, got)
+		}
+	}
+
+	// Disabling direct mapping is not a relaxation.
+	if isVSMBOptionsRelaxation(hcsschema.VirtualSmbShareOptions{ReadOnly: true}, hcsschema.VirtualSmbShareOptions{ReadOnly: true, NoDirectmap: true}) {
+		t.Fatal("disabling direct mapping must not be a relaxation")
+	}
+}
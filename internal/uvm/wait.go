@@ -11,6 +11,9 @@ func (uvm *UtilityVM) waitForOutput() {
 	if uvm.outputProcessingDone != nil {
 		<-uvm.outputProcessingDone
 	}
+	if uvm.consoleLogProcessingDone != nil {
+		<-uvm.consoleLogProcessingDone
+	}
 }
 
 // Wait waits synchronously for a utility VM to terminate.
@@ -23,6 +26,9 @@ func (uvm *UtilityVM) Wait() error {
 	if uvm.outputProcessingCancel != nil {
 		uvm.outputProcessingCancel()
 	}
+	if uvm.consoleLogProcessingCancel != nil {
+		uvm.consoleLogProcessingCancel()
+	}
 	uvm.waitForOutput()
 
 	return err
@@ -40,6 +46,9 @@ func (uvm *UtilityVM) WaitExpectedError(expected error) error {
 	if uvm.outputProcessingCancel != nil {
 		uvm.outputProcessingCancel()
 	}
+	if uvm.consoleLogProcessingCancel != nil {
+		uvm.consoleLogProcessingCancel()
+	}
 	uvm.waitForOutput()
 
 	return err